@@ -0,0 +1,89 @@
+package redismq
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// redisConn is the subset of the go-redis client surface redismq needs.
+// *goredis.Client and *goredis.ClusterClient both satisfy it, which is what
+// lets ConnectionConfig hand either one to a Queue without the rest of the
+// package caring which topology it's talking to.
+type redisConn interface {
+	goredis.Cmdable
+}
+
+// ConnectionConfig describes how a Queue should reach Redis. Build one with
+// NewConnectionConfig from a DSN, or set Client/Cluster/Failover directly
+// when the caller already constructed one of the modern go-redis clients.
+// Failover is a *goredis.Client too: NewFailoverClient just points a regular
+// client at a set of sentinels instead of a fixed address.
+type ConnectionConfig struct {
+	Client   *goredis.Client
+	Cluster  *goredis.ClusterClient
+	Failover *goredis.Client
+}
+
+// NewConnectionConfig parses a redis://, rediss://, redis-sentinel:// or
+// redis-cluster:// DSN into a ConnectionConfig. redis-sentinel URIs take the
+// master name via a "master" query parameter; redis-cluster URIs take a
+// comma-separated host list.
+func NewConnectionConfig(dsn string) (*ConnectionConfig, error) {
+	u, err := url.Parse(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "redis", "rediss":
+		opts, err := goredis.ParseURL(dsn)
+		if err != nil {
+			return nil, err
+		}
+		return &ConnectionConfig{Client: goredis.NewClient(opts)}, nil
+	case "redis-sentinel":
+		master := u.Query().Get("master")
+		if master == "" {
+			return nil, fmt.Errorf("redis-sentinel DSN requires a master query parameter")
+		}
+		password, _ := u.User.Password()
+		return &ConnectionConfig{Failover: goredis.NewFailoverClient(&goredis.FailoverOptions{
+			MasterName:    master,
+			SentinelAddrs: strings.Split(u.Host, ","),
+			Password:      password,
+		})}, nil
+	case "redis-cluster":
+		password, _ := u.User.Password()
+		return &ConnectionConfig{Cluster: goredis.NewClusterClient(&goredis.ClusterOptions{
+			Addrs:    strings.Split(u.Host, ","),
+			Password: password,
+		})}, nil
+	default:
+		return nil, fmt.Errorf("unsupported redismq DSN scheme %q", u.Scheme)
+	}
+}
+
+// client returns whichever of Client, Cluster or Failover was configured.
+func (c *ConnectionConfig) client() (redisConn, error) {
+	switch {
+	case c.Client != nil:
+		return c.Client, nil
+	case c.Cluster != nil:
+		return c.Cluster, nil
+	case c.Failover != nil:
+		return c.Failover, nil
+	default:
+		return nil, fmt.Errorf("ConnectionConfig has no client configured")
+	}
+}
+
+// hashTag wraps a queue name in Redis Cluster hash tag braces so every key
+// belonging to the same queue - input, per-consumer working lists, the
+// heartbeat and rate keys, the workers set - hashes to the same slot and can
+// keep participating in RPopLPush/BRPopLPush pairs under cluster mode.
+func hashTag(name string) string {
+	return "{" + name + "}"
+}
@@ -0,0 +1,75 @@
+package redismq
+
+import "testing"
+
+func TestNewConnectionConfig(t *testing.T) {
+	cases := []struct {
+		name    string
+		dsn     string
+		wantErr bool
+		check   func(*testing.T, *ConnectionConfig)
+	}{
+		{
+			name: "single node",
+			dsn:  "redis://localhost:6379/0",
+			check: func(t *testing.T, c *ConnectionConfig) {
+				if c.Client == nil {
+					t.Fatal("expected Client to be set")
+				}
+			},
+		},
+		{
+			name: "tls",
+			dsn:  "rediss://localhost:6379/0",
+			check: func(t *testing.T, c *ConnectionConfig) {
+				if c.Client == nil {
+					t.Fatal("expected Client to be set")
+				}
+			},
+		},
+		{
+			name: "sentinel",
+			dsn:  "redis-sentinel://localhost:26379?master=mymaster",
+			check: func(t *testing.T, c *ConnectionConfig) {
+				if c.Failover == nil {
+					t.Fatal("expected Failover to be set")
+				}
+			},
+		},
+		{
+			name:    "sentinel without master",
+			dsn:     "redis-sentinel://localhost:26379",
+			wantErr: true,
+		},
+		{
+			name: "cluster",
+			dsn:  "redis-cluster://host1:6379,host2:6379",
+			check: func(t *testing.T, c *ConnectionConfig) {
+				if c.Cluster == nil {
+					t.Fatal("expected Cluster to be set")
+				}
+			},
+		},
+		{
+			name:    "unsupported scheme",
+			dsn:     "http://localhost",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			config, err := NewConnectionConfig(tc.dsn)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatal("expected an error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			tc.check(t, config)
+		})
+	}
+}
@@ -1,33 +1,31 @@
 package redismq
 
 import (
+	"context"
 	"fmt"
 	"time"
 
-	"gopkg.in/redis.v2"
+	goredis "github.com/go-redis/redis/v8"
 )
 
 // Consumer are used for reading from queues
 type Consumer struct {
 	Name  string
 	Queue *Queue
-}
-
-type readTimeout bool
-
-func (readTimeout) Error() string {
-	return "Timeout occurred reading from the consumer"
-}
 
-func (t readTimeout) Timeout() bool {
-	return bool(t)
+	cancelHeartbeat context.CancelFunc
 }
 
 // AddConsumer returns a conumser that can write from the queue
 func (queue *Queue) AddConsumer(name string) (c *Consumer, err error) {
 	c = &Consumer{Name: name, Queue: queue}
+	if queue.UseStreams {
+		if err := queue.ensureStreamGroup(context.Background()); err != nil {
+			return nil, err
+		}
+	}
 	//check uniqueness and start heartbeat
-	added, err := queue.redisClient.SAdd(queueWorkersKey(queue.Name), name).Result()
+	added, err := queue.redisClient.SAdd(context.Background(), queueWorkersKey(queue.Name), name).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -40,25 +38,58 @@ func (queue *Queue) AddConsumer(name string) (c *Consumer, err error) {
 	return c, nil
 }
 
+// Close stops this consumer's heartbeat, fixing the goroutine leak from AddConsumer.
+func (consumer *Consumer) Close() error {
+	if consumer.cancelHeartbeat != nil {
+		consumer.cancelHeartbeat()
+	}
+	return nil
+}
+
+// withTimeout returns a context bound to timeout, or context.Background()
+// unchanged when timeout <= 0 so callers can keep asking to block forever.
+func withTimeout(timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), timeout)
+}
+
 // Get returns a single package from the queue (blocking)
 func (consumer *Consumer) Get(timeout time.Duration) (*Package, error) {
+	ctx, cancel := withTimeout(timeout)
+	defer cancel()
+	return consumer.GetContext(ctx)
+}
+
+// GetContext returns a single package from the queue, blocking until one
+// becomes available or ctx is done. Cancelling ctx - either via its own
+// deadline or an explicit cancel - unblocks the read.
+func (consumer *Consumer) GetContext(ctx context.Context) (*Package, error) {
 	if consumer.HasUnacked() {
 		return nil, fmt.Errorf("unacked Packages found")
 	}
-	pkg, err := consumer.unsafeGet(timeout)
-	if err == redis.Nil {
-		return pkg, readTimeout(true)
-	} else {
-		return pkg, err
-	}
+	return consumer.unsafeGetContext(ctx)
 }
 
 // NoWaitGet returns a single package from the queue (returns nil, nil if no package in queue)
 func (consumer *Consumer) NoWaitGet() (*Package, error) {
+	return consumer.NoWaitGetContext(context.Background())
+}
+
+// NoWaitGetContext is the context-aware variant of NoWaitGet.
+func (consumer *Consumer) NoWaitGetContext(ctx context.Context) (*Package, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	if consumer.HasUnacked() {
 		return nil, fmt.Errorf("unacked Packages found")
 	}
+	if consumer.Queue.UseStreams {
+		return consumer.noWaitGetStream(ctx)
+	}
 	answer := consumer.Queue.redisClient.RPopLPush(
+		ctx,
 		queueInputKey(consumer.Queue.Name),
 		consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name),
 	)
@@ -74,33 +105,53 @@ func (consumer *Consumer) NoWaitGet() (*Package, error) {
 
 // MultiGet returns an array of packages from the queue
 func (consumer *Consumer) MultiGet(length int, timeout time.Duration) ([]*Package, error) {
-	var collection []*Package
+	ctx, cancel := withTimeout(timeout)
+	defer cancel()
+	return consumer.MultiGetContext(ctx, length)
+}
+
+// MultiGetContext is the context-aware variant of MultiGet.
+func (consumer *Consumer) MultiGetContext(ctx context.Context, length int) ([]*Package, error) {
 	if consumer.HasUnacked() {
 		return nil, fmt.Errorf("unacked Packages found")
 	}
+	if consumer.Queue.UseStreams {
+		return consumer.multiGetStream(ctx, length, contextTimeout(ctx))
+	}
+	return consumer.multiGet(ctx, length)
+}
+
+func (consumer *Consumer) multiGet(ctx context.Context, length int) ([]*Package, error) {
+	var collection []*Package
+	timeout := contextTimeout(ctx)
 
 	// TODO maybe use transactions for rollback in case of errors?
-	reqs, err := consumer.Queue.redisClient.Pipelined(func(c *redis.Pipeline) error {
-		c.BRPopLPush(
+	reqs, err := consumer.Queue.redisClient.Pipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.BRPopLPush(
+			ctx,
 			queueInputKey(consumer.Queue.Name),
 			consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name),
-			int64(timeout/time.Second),
+			timeout,
 		)
 		for i := 1; i < length; i++ {
-			c.RPopLPush(
+			pipe.RPopLPush(
+				ctx,
 				queueInputKey(consumer.Queue.Name),
 				consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name),
 			)
 		}
 		return nil
 	})
-	if err != nil && err != redis.Nil {
+	if err != nil && err != goredis.Nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		return nil, err
 	}
 
 	for _, answer := range reqs {
 		switch answer := answer.(type) {
-		case *redis.StringCmd:
+		case *goredis.StringCmd:
 			if answer.Val() == "" {
 				continue
 			}
@@ -128,6 +179,7 @@ func (consumer *Consumer) GetUnacked() (*Package, error) {
 		return nil, fmt.Errorf("no unacked Packages found")
 	}
 	answer := consumer.Queue.redisClient.LIndex(
+		context.Background(),
 		consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name),
 		-1,
 	)
@@ -136,6 +188,9 @@ func (consumer *Consumer) GetUnacked() (*Package, error) {
 
 // HasUnacked returns true if the consumers has unacked packages
 func (consumer *Consumer) HasUnacked() bool {
+	if consumer.Queue.UseStreams {
+		return consumer.hasUnackedStream(context.Background())
+	}
 	if consumer.GetUnackedLength() != 0 {
 		return true
 	}
@@ -144,12 +199,13 @@ func (consumer *Consumer) HasUnacked() bool {
 
 // GetUnackedLength returns the number of packages in the unacked queue
 func (consumer *Consumer) GetUnackedLength() int64 {
-	return consumer.Queue.redisClient.LLen(consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name)).Val()
+	return consumer.Queue.redisClient.LLen(context.Background(), consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name)).Val()
 }
 
 // GetFailed returns a single packages from the failed queue of this consumer
 func (consumer *Consumer) GetFailed() (*Package, error) {
 	answer := consumer.Queue.redisClient.RPopLPush(
+		context.Background(),
 		queueFailedKey(consumer.Queue.Name),
 		consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name),
 	)
@@ -162,11 +218,14 @@ func (consumer *Consumer) GetFailed() (*Package, error) {
 
 // ResetWorking deletes! all messages in the working queue of this consumer
 func (consumer *Consumer) ResetWorking() error {
-	return consumer.Queue.redisClient.Del(consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name)).Err()
+	return consumer.Queue.redisClient.Del(context.Background(), consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name)).Err()
 }
 
 // RequeueWorking requeues all packages from working to input
 func (consumer *Consumer) RequeueWorking() error {
+	if consumer.Queue.UseStreams {
+		return consumer.requeueWorkingStream(context.Background())
+	}
 	for consumer.HasUnacked() {
 		p, err := consumer.GetUnacked()
 		if err != nil {
@@ -178,11 +237,15 @@ func (consumer *Consumer) RequeueWorking() error {
 }
 
 func (consumer *Consumer) ackPackage(p *Package) error {
-	return consumer.Queue.redisClient.RPop(consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name)).Err()
+	if consumer.Queue.UseStreams {
+		return consumer.ackPackageStream(context.Background(), p)
+	}
+	return consumer.Queue.redisClient.RPop(context.Background(), consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name)).Err()
 }
 
 func (consumer *Consumer) requeuePackage(p *Package) error {
 	answer := consumer.Queue.redisClient.RPopLPush(
+		context.Background(),
 		consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name),
 		queueInputKey(consumer.Queue.Name),
 	)
@@ -192,20 +255,30 @@ func (consumer *Consumer) requeuePackage(p *Package) error {
 
 func (consumer *Consumer) failPackage(p *Package) error {
 	return consumer.Queue.redisClient.RPopLPush(
+		context.Background(),
 		consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name),
 		queueFailedKey(consumer.Queue.Name),
 	).Err()
 }
 
 func (consumer *Consumer) startHeartbeat() {
+	ctx, cancel := context.WithCancel(context.Background())
+	consumer.cancelHeartbeat = cancel
+
 	firstWrite := make(chan bool, 1)
 	go func() {
 		firstRun := true
 		for {
-			consumer.Queue.redisClient.SetEx(
+			select {
+			case <-ctx.Done():
+				return
+			default:
+			}
+			consumer.Queue.redisClient.Set(
+				ctx,
 				consumerHeartbeatKey(consumer.Queue.Name, consumer.Name),
-				time.Second,
 				"ping",
+				time.Second,
 			)
 			if firstRun {
 				firstWrite <- true
@@ -218,7 +291,7 @@ func (consumer *Consumer) startHeartbeat() {
 	return
 }
 
-func (consumer *Consumer) parseRedisAnswer(answer *redis.StringCmd) (*Package, error) {
+func (consumer *Consumer) parseRedisAnswer(answer *goredis.StringCmd) (*Package, error) {
 	if answer.Err() != nil {
 		return nil, answer.Err()
 	}
@@ -229,15 +302,44 @@ func (consumer *Consumer) parseRedisAnswer(answer *redis.StringCmd) (*Package, e
 	return p, nil
 }
 
-func (consumer *Consumer) unsafeGet(timeout time.Duration) (*Package, error) {
+// unsafeGetContext issues the blocking read directly against the
+// ctx-aware client, so an explicit cancel (not just a deadline) aborts the
+// in-flight BRPopLPush/XREADGROUP instead of leaking it.
+func (consumer *Consumer) unsafeGetContext(ctx context.Context) (*Package, error) {
+	timeout := contextTimeout(ctx)
+	if consumer.Queue.UseStreams {
+		return consumer.getStream(ctx, timeout)
+	}
 	answer := consumer.Queue.redisClient.BRPopLPush(
+		ctx,
 		queueInputKey(consumer.Queue.Name),
 		consumerWorkingQueueKey(consumer.Queue.Name, consumer.Name),
-		int64(timeout/time.Second),
+		timeout,
 	)
+	if err := answer.Err(); err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err == goredis.Nil {
+			return nil, context.DeadlineExceeded
+		}
+		return nil, err
+	}
 	consumer.Queue.incrRate(
 		consumerWorkingRateKey(consumer.Queue.Name, consumer.Name),
 		1,
 	)
 	return consumer.parseRedisAnswer(answer)
 }
+
+// contextTimeout returns time until ctx's deadline, or 0 (block indefinitely) if it has none.
+func contextTimeout(ctx context.Context) time.Duration {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0
+	}
+	if d := time.Until(deadline); d > 0 {
+		return d
+	}
+	return 0
+}
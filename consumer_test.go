@@ -0,0 +1,110 @@
+package redismq
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// fakeConn embeds a nil Cmdable so it satisfies redisConn while only the
+// methods a given test needs are actually implemented below.
+type fakeConn struct {
+	goredis.Cmdable
+	setCalls int32
+}
+
+func (f *fakeConn) BRPopLPush(ctx context.Context, source, destination string, timeout time.Duration) *goredis.StringCmd {
+	cmd := goredis.NewStringCmd(ctx)
+	<-ctx.Done()
+	cmd.SetErr(ctx.Err())
+	return cmd
+}
+
+func (f *fakeConn) LLen(ctx context.Context, key string) *goredis.IntCmd {
+	cmd := goredis.NewIntCmd(ctx)
+	cmd.SetVal(0)
+	return cmd
+}
+
+func (f *fakeConn) XReadGroup(ctx context.Context, a *goredis.XReadGroupArgs) *goredis.XStreamSliceCmd {
+	cmd := goredis.NewXStreamSliceCmd(ctx)
+	<-ctx.Done()
+	cmd.SetErr(ctx.Err())
+	return cmd
+}
+
+func (f *fakeConn) XPendingExt(ctx context.Context, a *goredis.XPendingExtArgs) *goredis.XPendingExtCmd {
+	cmd := goredis.NewXPendingExtCmd(ctx)
+	cmd.SetVal([]goredis.XPendingExt{})
+	return cmd
+}
+
+func (f *fakeConn) Set(ctx context.Context, key string, value interface{}, expiration time.Duration) *goredis.StatusCmd {
+	atomic.AddInt32(&f.setCalls, 1)
+	cmd := goredis.NewStatusCmd(ctx)
+	cmd.SetVal("OK")
+	return cmd
+}
+
+func waitForUnblock(t *testing.T, done <-chan error, want error) {
+	t.Helper()
+	select {
+	case err := <-done:
+		if err != want {
+			t.Fatalf("expected %v, got %v", want, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("blocking call did not return after cancel; it leaked")
+	}
+}
+
+// An explicit cancel (no deadline) must unblock GetContext immediately
+// instead of leaving the BRPopLPush call - and its goroutine - running.
+func TestGetContext_CancelUnblocksPromptly(t *testing.T) {
+	consumer := &Consumer{Name: "c", Queue: &Queue{Name: "q", redisClient: &fakeConn{}}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := consumer.GetContext(ctx)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	waitForUnblock(t, done, context.Canceled)
+}
+
+// Same guarantee under the streams backend, where MultiGetContext blocks on XREADGROUP.
+func TestMultiGetContext_StreamCancelUnblocksPromptly(t *testing.T) {
+	consumer := &Consumer{Name: "c", Queue: &Queue{Name: "q", redisClient: &fakeConn{}, UseStreams: true}}
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := consumer.MultiGetContext(ctx, 1)
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+
+	waitForUnblock(t, done, context.Canceled)
+}
+
+// Close must stop the heartbeat goroutine started by AddConsumer/startHeartbeat.
+func TestClose_StopsHeartbeatGoroutine(t *testing.T) {
+	fake := &fakeConn{}
+	consumer := &Consumer{Name: "c", Queue: &Queue{Name: "q", redisClient: fake}}
+
+	consumer.startHeartbeat()
+	consumer.Close()
+
+	calls := atomic.LoadInt32(&fake.setCalls)
+	time.Sleep(700 * time.Millisecond) // longer than the heartbeat's 500ms tick
+	if got := atomic.LoadInt32(&fake.setCalls); got != calls {
+		t.Fatalf("heartbeat kept writing after Close: %d -> %d", calls, got)
+	}
+}
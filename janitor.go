@@ -0,0 +1,184 @@
+package redismq
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// defaultJanitorGrace is how many consecutive missed heartbeats a consumer
+// is allowed before the janitor considers it dead and reclaims its work.
+const defaultJanitorGrace = 3
+
+// defaultJanitorMaxRedeliveries is how many times the janitor will put a
+// message back on the input queue before giving up on it and routing it to
+// the failed queue instead, so a poison message doesn't get reclaimed forever.
+const defaultJanitorMaxRedeliveries = 5
+
+// reclaimScript drains a dead consumer's working queue message by message,
+// routing each one back to input or, past the redelivery limit in ARGV[1], to failed.
+const reclaimScript = `
+local working = KEYS[1]
+local input = KEYS[2]
+local failed = KEYS[3]
+local maxRedeliveries = tonumber(ARGV[1])
+local requeued = 0
+local dropped = 0
+while true do
+	local msg = redis.call('RPOP', working)
+	if not msg then
+		break
+	end
+	local ok, decoded = pcall(cjson.decode, msg)
+	if ok and type(decoded) == 'table' then
+		decoded.DeliveryCount = (decoded.DeliveryCount or 0) + 1
+		if decoded.DeliveryCount > maxRedeliveries then
+			redis.call('LPUSH', failed, cjson.encode(decoded))
+			dropped = dropped + 1
+		else
+			redis.call('LPUSH', input, cjson.encode(decoded))
+			requeued = requeued + 1
+		end
+	else
+		redis.call('LPUSH', input, msg)
+		requeued = requeued + 1
+	end
+end
+return {requeued, dropped}
+`
+
+// StartJanitor launches a background goroutine that periodically looks for
+// consumers whose heartbeat has expired and requeues whatever they had
+// pulled into their working list, so a crashed consumer doesn't strand
+// messages forever. Call StopJanitor to shut it down.
+func (queue *Queue) StartJanitor(interval time.Duration) {
+	queue.janitorMu.Lock()
+	defer queue.janitorMu.Unlock()
+	if queue.janitorStop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	queue.janitorStop = stop
+	if queue.janitorMissed == nil {
+		queue.janitorMissed = make(map[string]int)
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				queue.reclaimDeadConsumers()
+			}
+		}
+	}()
+}
+
+// StopJanitor stops a previously started janitor. It is a no-op if no
+// janitor is running.
+func (queue *Queue) StopJanitor() {
+	queue.janitorMu.Lock()
+	defer queue.janitorMu.Unlock()
+	if queue.janitorStop == nil {
+		return
+	}
+	close(queue.janitorStop)
+	queue.janitorStop = nil
+}
+
+// reclaimDeadConsumers walks the queue's registered workers, and for any
+// whose heartbeat key has been missing for queue.JanitorGrace consecutive
+// sweeps, atomically moves its working queue back onto the input queue (or
+// the failed queue, past the redelivery limit) and drops it from the
+// workers set.
+func (queue *Queue) reclaimDeadConsumers() {
+	ctx := context.Background()
+	members, err := queue.redisClient.SMembers(ctx, queueWorkersKey(queue.Name)).Result()
+	if err != nil {
+		log.Printf("redismq janitor: could not list workers for %q: %s", queue.Name, err)
+		return
+	}
+
+	queue.janitorMu.Lock()
+	defer queue.janitorMu.Unlock()
+
+	alive := make(map[string]bool, len(members))
+	for _, name := range members {
+		alive[name] = true
+		exists, err := queue.redisClient.Exists(ctx, consumerHeartbeatKey(queue.Name, name)).Result()
+		if err != nil {
+			log.Printf("redismq janitor: could not check heartbeat for %q/%q: %s", queue.Name, name, err)
+			continue
+		}
+		if exists > 0 {
+			delete(queue.janitorMissed, name)
+			continue
+		}
+
+		if !bumpMissed(queue.janitorMissed, name, queue.janitorGrace()) {
+			continue
+		}
+
+		queue.reclaimConsumer(ctx, name)
+		delete(queue.janitorMissed, name)
+	}
+
+	for name := range queue.janitorMissed {
+		if !alive[name] {
+			delete(queue.janitorMissed, name)
+		}
+	}
+}
+
+// reclaimConsumer moves everything in name's working queue back onto the
+// input queue (or failed, once a message has exhausted its redeliveries)
+// and removes name from the workers set.
+func (queue *Queue) reclaimConsumer(ctx context.Context, name string) {
+	result, err := queue.redisClient.Eval(
+		ctx,
+		reclaimScript,
+		[]string{
+			consumerWorkingQueueKey(queue.Name, name),
+			queueInputKey(queue.Name),
+			queueFailedKey(queue.Name),
+		},
+		queue.janitorMaxRedeliveries(),
+	).Result()
+	if err != nil {
+		log.Printf("redismq janitor: could not reclaim working queue for %q/%q: %s", queue.Name, name, err)
+		return
+	}
+
+	if err := queue.redisClient.SRem(ctx, queueWorkersKey(queue.Name), name).Err(); err != nil {
+		log.Printf("redismq janitor: could not remove dead worker %q/%q: %s", queue.Name, name, err)
+		return
+	}
+
+	log.Printf("redismq janitor: reclaimed dead consumer %q/%q: %v", queue.Name, name, result)
+}
+
+// bumpMissed records a missed heartbeat for name and reports whether it has
+// now reached grace, the point at which the janitor should reclaim it.
+func bumpMissed(missed map[string]int, name string, grace int) bool {
+	missed[name]++
+	return missed[name] >= grace
+}
+
+// janitorGrace falls back to defaultJanitorGrace for a Queue built without NewQueue.
+func (queue *Queue) janitorGrace() int {
+	if queue.JanitorGrace <= 0 {
+		return defaultJanitorGrace
+	}
+	return queue.JanitorGrace
+}
+
+// janitorMaxRedeliveries falls back to defaultJanitorMaxRedeliveries for a Queue built without NewQueue.
+func (queue *Queue) janitorMaxRedeliveries() int {
+	if queue.JanitorMaxRedeliveries <= 0 {
+		return defaultJanitorMaxRedeliveries
+	}
+	return queue.JanitorMaxRedeliveries
+}
@@ -0,0 +1,19 @@
+package redismq
+
+import "testing"
+
+func TestBumpMissed(t *testing.T) {
+	missed := map[string]int{}
+
+	for i := 1; i < defaultJanitorGrace; i++ {
+		if bumpMissed(missed, "consumer-a", defaultJanitorGrace) {
+			t.Fatalf("bumpMissed reached grace early on miss %d", i)
+		}
+	}
+	if !bumpMissed(missed, "consumer-a", defaultJanitorGrace) {
+		t.Fatal("expected bumpMissed to reach grace on the final miss")
+	}
+	if missed["consumer-b"] != 0 {
+		t.Fatal("expected an unrelated consumer's count to be untouched")
+	}
+}
@@ -0,0 +1,71 @@
+package redismq
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Package wraps a single message payload as it moves through a Queue,
+// carrying enough bookkeeping to be acked, requeued or failed.
+type Package struct {
+	// ID identifies a delayed Package so it can later be found by
+	// Producer.CancelDelayed. It is only set on packages scheduled via
+	// PutDelayed/PutAfter.
+	ID string
+
+	Payload string
+
+	// DeliveryCount is how many times the janitor has redelivered this
+	// package after reclaiming it from a dead consumer's working queue.
+	DeliveryCount int
+
+	Collection *[]*Package `json:"-"`
+
+	Queue    *Queue    `json:"-"`
+	Consumer *Consumer `json:"-"`
+
+	// streamID is the Redis Streams entry id this package was read as,
+	// when its Queue has UseStreams set.
+	streamID string
+}
+
+// generatePackageID returns a random hex id for a delayed Package.
+func generatePackageID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func marshalPackage(p *Package) (string, error) {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+func unmarshalPackage(payload string, queue *Queue, consumer *Consumer) (*Package, error) {
+	p := &Package{}
+	if err := json.Unmarshal([]byte(payload), p); err != nil {
+		return nil, err
+	}
+	p.Queue = queue
+	p.Consumer = consumer
+	return p, nil
+}
+
+// Ack acknowledges successful processing of p.
+func (p *Package) Ack() error {
+	return p.Consumer.ackPackage(p)
+}
+
+// Requeue puts p back on its queue's input list for redelivery.
+func (p *Package) Requeue() error {
+	return p.Consumer.requeuePackage(p)
+}
+
+// Fail moves p to its queue's failed list.
+func (p *Package) Fail() error {
+	return p.Consumer.failPackage(p)
+}
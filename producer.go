@@ -0,0 +1,39 @@
+package redismq
+
+import (
+	"context"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// Producer is used for writing to a queue.
+type Producer struct {
+	Queue *Queue
+}
+
+// NewProducer returns a Producer that writes to queue.
+func NewProducer(queue *Queue) *Producer {
+	return &Producer{Queue: queue}
+}
+
+// Put enqueues payload for immediate delivery.
+func (producer *Producer) Put(payload string) error {
+	marshalled, err := marshalPackage(&Package{Payload: payload})
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	if producer.Queue.UseStreams {
+		return producer.Queue.redisClient.XAdd(ctx, &goredis.XAddArgs{
+			Stream: queueInputKey(producer.Queue.Name),
+			Values: map[string]interface{}{"payload": marshalled},
+		}).Err()
+	}
+
+	if err := producer.Queue.redisClient.LPush(ctx, queueInputKey(producer.Queue.Name), marshalled).Err(); err != nil {
+		return err
+	}
+	producer.Queue.incrRate(queueInputRateKey(producer.Queue.Name), 1)
+	return nil
+}
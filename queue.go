@@ -0,0 +1,92 @@
+package redismq
+
+import (
+	"context"
+	"sync"
+)
+
+// Queue represents a single named queue: an input list, a failed list, and
+// the set of consumers currently reading from it.
+type Queue struct {
+	Name        string
+	redisClient redisConn
+
+	// UseStreams switches Get/MultiGet/NoWaitGet/HasUnacked/RequeueWorking/
+	// ackPackage over to a Redis Streams consumer group backend instead of
+	// the default BRPopLPush/list backend.
+	UseStreams bool
+
+	// JanitorGrace and JanitorMaxRedeliveries configure StartJanitor's reclaim
+	// policy. NewQueue defaults them to defaultJanitorGrace/defaultJanitorMaxRedeliveries.
+	JanitorGrace           int
+	JanitorMaxRedeliveries int
+
+	// janitorMu guards janitorStop and janitorMissed, since StartJanitor's
+	// background goroutine and StopJanitor can race with each other.
+	janitorMu     sync.Mutex
+	janitorStop   chan struct{}
+	janitorMissed map[string]int
+
+	// schedulerMu guards schedulerStop against a StartScheduler/StopScheduler race.
+	schedulerMu   sync.Mutex
+	schedulerStop chan struct{}
+}
+
+// NewQueue returns a Queue named name, reaching Redis through whichever
+// client config describes.
+func NewQueue(config *ConnectionConfig, name string) (*Queue, error) {
+	client, err := config.client()
+	if err != nil {
+		return nil, err
+	}
+	return &Queue{
+		Name:                   name,
+		redisClient:            client,
+		JanitorGrace:           defaultJanitorGrace,
+		JanitorMaxRedeliveries: defaultJanitorMaxRedeliveries,
+	}, nil
+}
+
+// Key helpers below all wrap the queue name in hashTag's {braces} so every
+// key belonging to one logical queue - input, per-consumer working lists,
+// heartbeats, rates, the workers set - lands on the same cluster hash slot
+// and RPopLPush/BRPopLPush pairs keep working under Redis Cluster.
+
+func queueInputKey(name string) string {
+	return "redismq::" + hashTag(name) + "::input"
+}
+
+func queueFailedKey(name string) string {
+	return "redismq::" + hashTag(name) + "::failed"
+}
+
+func queueWorkersKey(name string) string {
+	return "redismq::" + hashTag(name) + "::workers"
+}
+
+func queueInputRateKey(name string) string {
+	return "redismq::" + hashTag(name) + "::input::rate"
+}
+
+func consumerWorkingQueueKey(queueName, consumerName string) string {
+	return "redismq::" + hashTag(queueName) + "::" + consumerName + "::working"
+}
+
+func consumerHeartbeatKey(queueName, consumerName string) string {
+	return "redismq::" + hashTag(queueName) + "::" + consumerName + "::heartbeat"
+}
+
+func consumerWorkingRateKey(queueName, consumerName string) string {
+	return "redismq::" + hashTag(queueName) + "::" + consumerName + "::working::rate"
+}
+
+// incrRate bumps a throughput counter key.
+func (queue *Queue) incrRate(key string, amount int64) {
+	queue.redisClient.IncrBy(context.Background(), key, amount)
+}
+
+// isActiveConsumer reports whether name is currently heartbeating.
+func (queue *Queue) isActiveConsumer(name string) bool {
+	exists, err := queue.redisClient.Exists(context.Background(), consumerHeartbeatKey(queue.Name, name)).Result()
+	return err == nil && exists > 0
+}
@@ -0,0 +1,159 @@
+package redismq
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// queueDelayedKey returns the sorted set that holds messages scheduled for
+// future delivery, scored by their unix-nano deliver time.
+func queueDelayedKey(name string) string {
+	return "redismq::" + hashTag(name) + "::delayed"
+}
+
+// defaultSchedulerBatch caps how many due messages a single scheduler tick
+// moves onto the input queue, so one very large backlog can't starve other
+// queues sharing the same redis instance.
+const defaultSchedulerBatch = 100
+
+// scheduleScript moves up to ARGV[2] messages due by now from the delayed
+// ZSET onto the input list, returning how many messages it moved.
+const scheduleScript = `
+local delayed = KEYS[1]
+local input = KEYS[2]
+local now = ARGV[1]
+local limit = ARGV[2]
+local due = redis.call('ZRANGEBYSCORE', delayed, '-inf', now, 'LIMIT', 0, limit)
+for _, payload in ipairs(due) do
+	redis.call('ZREM', delayed, payload)
+	redis.call('LPUSH', input, payload)
+end
+return #due
+`
+
+// PutDelayed schedules payload for delivery at deliverAt, returning an id
+// that can later be passed to CancelDelayed to revoke it.
+func (producer *Producer) PutDelayed(payload string, deliverAt time.Time) (string, error) {
+	p := &Package{Payload: payload, ID: generatePackageID()}
+	marshalled, err := marshalPackage(p)
+	if err != nil {
+		return "", err
+	}
+	err = producer.Queue.redisClient.ZAdd(
+		context.Background(),
+		queueDelayedKey(producer.Queue.Name),
+		&goredis.Z{Score: float64(deliverAt.UnixNano()), Member: marshalled},
+	).Err()
+	if err != nil {
+		return "", err
+	}
+	return p.ID, nil
+}
+
+// PutAfter schedules payload for delivery delay from now.
+func (producer *Producer) PutAfter(payload string, delay time.Duration) (string, error) {
+	return producer.PutDelayed(payload, time.Now().Add(delay))
+}
+
+// CancelDelayed revokes a message scheduled with PutDelayed/PutAfter before
+// it is delivered. It returns an error if no delayed message with that id
+// is found, which also covers the case where it has already been delivered.
+func (producer *Producer) CancelDelayed(id string) error {
+	ctx := context.Background()
+	members, err := producer.Queue.redisClient.ZRange(ctx, queueDelayedKey(producer.Queue.Name), 0, -1).Result()
+	if err != nil {
+		return err
+	}
+	for _, member := range members {
+		p, err := unmarshalPackage(member, producer.Queue, nil)
+		if err != nil {
+			continue
+		}
+		if p.ID == id {
+			return producer.Queue.redisClient.ZRem(ctx, queueDelayedKey(producer.Queue.Name), member).Err()
+		}
+	}
+	return fmt.Errorf("no delayed package with id %q found", id)
+}
+
+// StartScheduler launches a background goroutine that periodically moves
+// due messages from the delayed ZSET onto the input queue. Call
+// StopScheduler to shut it down.
+func (queue *Queue) StartScheduler(tick time.Duration) {
+	queue.schedulerMu.Lock()
+	defer queue.schedulerMu.Unlock()
+	if queue.schedulerStop != nil {
+		return
+	}
+	stop := make(chan struct{})
+	queue.schedulerStop = stop
+
+	go func() {
+		ticker := time.NewTicker(tick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				queue.deliverDue()
+			}
+		}
+	}()
+}
+
+// StopScheduler stops a previously started scheduler. It is a no-op if no
+// scheduler is running.
+func (queue *Queue) StopScheduler() {
+	queue.schedulerMu.Lock()
+	defer queue.schedulerMu.Unlock()
+	if queue.schedulerStop == nil {
+		return
+	}
+	close(queue.schedulerStop)
+	queue.schedulerStop = nil
+}
+
+// deliverDue moves up to defaultSchedulerBatch messages due by now from the
+// delayed ZSET onto input.
+func (queue *Queue) deliverDue() {
+	result, err := queue.redisClient.Eval(
+		context.Background(),
+		scheduleScript,
+		[]string{queueDelayedKey(queue.Name), queueInputKey(queue.Name)},
+		time.Now().UnixNano(), defaultSchedulerBatch,
+	).Result()
+	if err != nil {
+		return
+	}
+	if n := deliveredCount(result); n > 0 {
+		queue.incrRate(queueInputRateKey(queue.Name), n)
+	}
+}
+
+// deliveredCount reads the integer count scheduleScript returns out of the
+// interface{} EVAL hands back.
+func deliveredCount(result interface{}) int64 {
+	n, ok := result.(int64)
+	if !ok {
+		return 0
+	}
+	return n
+}
+
+// FailWithDelay acks p and reschedules it for redelivery after d instead of
+// routing it straight to the failed queue, for retry-with-backoff.
+func (p *Package) FailWithDelay(d time.Duration) error {
+	if p.Consumer == nil {
+		return fmt.Errorf("package has no consumer to fail from")
+	}
+	if err := p.Consumer.ackPackage(p); err != nil {
+		return err
+	}
+	producer := &Producer{Queue: p.Queue}
+	_, err := producer.PutAfter(p.Payload, d)
+	return err
+}
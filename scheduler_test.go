@@ -0,0 +1,24 @@
+package redismq
+
+import "testing"
+
+func TestDeliveredCount(t *testing.T) {
+	cases := []struct {
+		name   string
+		result interface{}
+		want   int64
+	}{
+		{name: "int64", result: int64(3), want: 3},
+		{name: "zero", result: int64(0), want: 0},
+		{name: "wrong type", result: "0", want: 0},
+		{name: "nil", result: nil, want: 0},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := deliveredCount(tc.result); got != tc.want {
+				t.Fatalf("deliveredCount(%v) = %d, want %d", tc.result, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package redismq
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	goredis "github.com/go-redis/redis/v8"
+)
+
+// queueStreamGroupKey returns the consumer group name used for a queue's stream.
+func queueStreamGroupKey(name string) string {
+	return "redismq::" + hashTag(name) + "::group"
+}
+
+// ensureStreamGroup creates the queue's stream and consumer group on first use.
+func (queue *Queue) ensureStreamGroup(ctx context.Context) error {
+	err := queue.redisClient.XGroupCreateMkStream(
+		ctx,
+		queueInputKey(queue.Name),
+		queueStreamGroupKey(queue.Name),
+		"0",
+	).Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return err
+	}
+	return nil
+}
+
+// getStream is the UseStreams counterpart of unsafeGetContext.
+func (consumer *Consumer) getStream(ctx context.Context, timeout time.Duration) (*Package, error) {
+	streams, err := consumer.Queue.redisClient.XReadGroup(ctx, &goredis.XReadGroupArgs{
+		Group:    queueStreamGroupKey(consumer.Queue.Name),
+		Consumer: consumer.Name,
+		Streams:  []string{queueInputKey(consumer.Queue.Name), ">"},
+		Count:    1,
+		Block:    timeout,
+	}).Result()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err == goredis.Nil {
+			return nil, context.DeadlineExceeded
+		}
+		return nil, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, context.DeadlineExceeded
+	}
+	consumer.Queue.incrRate(consumerWorkingRateKey(consumer.Queue.Name, consumer.Name), 1)
+	return consumer.parseStreamMessage(streams[0].Messages[0])
+}
+
+// multiGetStream is the UseStreams counterpart of MultiGet.
+func (consumer *Consumer) multiGetStream(ctx context.Context, length int, timeout time.Duration) ([]*Package, error) {
+	streams, err := consumer.Queue.redisClient.XReadGroup(ctx, &goredis.XReadGroupArgs{
+		Group:    queueStreamGroupKey(consumer.Queue.Name),
+		Consumer: consumer.Name,
+		Streams:  []string{queueInputKey(consumer.Queue.Name), ">"},
+		Count:    int64(length),
+		Block:    timeout,
+	}).Result()
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if err == goredis.Nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if len(streams) == 0 {
+		return nil, nil
+	}
+
+	var collection []*Package
+	for _, message := range streams[0].Messages {
+		p, err := consumer.parseStreamMessage(message)
+		if err != nil {
+			return nil, err
+		}
+		p.Collection = &collection
+		collection = append(collection, p)
+	}
+	consumer.Queue.incrRate(consumerWorkingRateKey(consumer.Queue.Name, consumer.Name), int64(len(collection)))
+	return collection, nil
+}
+
+// noWaitGetStream is the UseStreams counterpart of NoWaitGet.
+func (consumer *Consumer) noWaitGetStream(ctx context.Context) (*Package, error) {
+	streams, err := consumer.Queue.redisClient.XReadGroup(ctx, &goredis.XReadGroupArgs{
+		Group:    queueStreamGroupKey(consumer.Queue.Name),
+		Consumer: consumer.Name,
+		Streams:  []string{queueInputKey(consumer.Queue.Name), ">"},
+		Count:    1,
+		Block:    -1,
+	}).Result()
+	if err == goredis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(streams) == 0 || len(streams[0].Messages) == 0 {
+		return nil, nil
+	}
+	consumer.Queue.incrRate(consumerWorkingRateKey(consumer.Queue.Name, consumer.Name), 1)
+	return consumer.parseStreamMessage(streams[0].Messages[0])
+}
+
+// hasUnackedStream is the UseStreams counterpart of HasUnacked, backed by XPENDING.
+func (consumer *Consumer) hasUnackedStream(ctx context.Context) bool {
+	pending, err := consumer.Queue.redisClient.XPendingExt(ctx, &goredis.XPendingExtArgs{
+		Stream:   queueInputKey(consumer.Queue.Name),
+		Group:    queueStreamGroupKey(consumer.Queue.Name),
+		Start:    "-",
+		End:      "+",
+		Count:    1,
+		Consumer: consumer.Name,
+	}).Result()
+	if err != nil {
+		return false
+	}
+	return len(pending) != 0
+}
+
+// ackPackageStream is the UseStreams counterpart of ackPackage.
+func (consumer *Consumer) ackPackageStream(ctx context.Context, p *Package) error {
+	return consumer.Queue.redisClient.XAck(
+		ctx,
+		queueInputKey(consumer.Queue.Name),
+		queueStreamGroupKey(consumer.Queue.Name),
+		p.streamID,
+	).Err()
+}
+
+// requeueWorkingStream is the UseStreams counterpart of RequeueWorking: it
+// claims this consumer's own pending entries, re-adds them to the stream,
+// then acks the originals.
+func (consumer *Consumer) requeueWorkingStream(ctx context.Context) error {
+	for {
+		pending, err := consumer.Queue.redisClient.XPendingExt(ctx, &goredis.XPendingExtArgs{
+			Stream:   queueInputKey(consumer.Queue.Name),
+			Group:    queueStreamGroupKey(consumer.Queue.Name),
+			Start:    "-",
+			End:      "+",
+			Count:    1000,
+			Consumer: consumer.Name,
+		}).Result()
+		if err != nil {
+			return err
+		}
+		if len(pending) == 0 {
+			return nil
+		}
+		if err := consumer.requeuePendingStream(ctx, pending); err != nil {
+			return err
+		}
+	}
+}
+
+// requeuePendingStream claims, re-adds and acks one batch of pending entries.
+func (consumer *Consumer) requeuePendingStream(ctx context.Context, pending []goredis.XPendingExt) error {
+	for _, entry := range pending {
+		claimed, err := consumer.Queue.redisClient.XClaim(ctx, &goredis.XClaimArgs{
+			Stream:   queueInputKey(consumer.Queue.Name),
+			Group:    queueStreamGroupKey(consumer.Queue.Name),
+			Consumer: consumer.Name,
+			MinIdle:  0,
+			Messages: []string{entry.ID},
+		}).Result()
+		if err != nil {
+			return err
+		}
+		for _, message := range claimed {
+			if _, err := consumer.Queue.redisClient.XAdd(ctx, &goredis.XAddArgs{
+				Stream: queueInputKey(consumer.Queue.Name),
+				Values: message.Values,
+			}).Result(); err != nil {
+				return err
+			}
+		}
+		if err := consumer.Queue.redisClient.XAck(
+			ctx,
+			queueInputKey(consumer.Queue.Name),
+			queueStreamGroupKey(consumer.Queue.Name),
+			entry.ID,
+		).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// parseStreamMessage turns a stream entry back into a Package, stamping it
+// with the stream id so it can be acked or claimed later.
+func (consumer *Consumer) parseStreamMessage(message goredis.XMessage) (*Package, error) {
+	payload, ok := message.Values["payload"].(string)
+	if !ok {
+		return nil, fmt.Errorf("stream message %s missing payload field", message.ID)
+	}
+	p, err := unmarshalPackage(payload, consumer.Queue, consumer)
+	if err != nil {
+		return nil, err
+	}
+	p.streamID = message.ID
+	return p, nil
+}